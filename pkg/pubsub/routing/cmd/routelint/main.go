@@ -0,0 +1,119 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command routelint compiles every subscription Match rule in a Dapr
+// subscription YAML file (or a directory of them) and reports any rule
+// that fails to compile, so invalid routing rules are caught in CI
+// instead of at delivery time in the sidecar.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/dapr/dapr/pkg/pubsub/routing"
+)
+
+// subscriptionYAML is the minimal shape of a Dapr Subscription resource
+// needed to extract its Match rules; unrelated fields are ignored.
+type subscriptionYAML struct {
+	Spec struct {
+		Routes struct {
+			Rules []struct {
+				Match string `yaml:"match"`
+				Path  string `yaml:"path"`
+			} `yaml:"rules"`
+		} `yaml:"routes"`
+	} `yaml:"spec"`
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: routelint <file-or-dir>...")
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, target := range os.Args[1:] {
+		if err := lintPath(target); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func lintPath(target string) error {
+	info, err := os.Stat(target)
+	if err != nil {
+		return fmt.Errorf("%s: %w", target, err)
+	}
+
+	if !info.IsDir() {
+		return lintFile(target)
+	}
+
+	// Accumulate errors across the whole walk instead of returning the
+	// first one: WalkDirFunc returning a non-nil error aborts the walk,
+	// which would stop linting a directory as soon as one bad YAML was
+	// found and hide every issue after it.
+	var errs []error
+	walkErr := filepath.WalkDir(target, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		if d.IsDir() || (filepath.Ext(path) != ".yaml" && filepath.Ext(path) != ".yml") {
+			return nil
+		}
+		if err := lintFile(path); err != nil {
+			errs = append(errs, err)
+		}
+		return nil
+	})
+	if walkErr != nil {
+		errs = append(errs, walkErr)
+	}
+	return errors.Join(errs...)
+}
+
+func lintFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	var sub subscriptionYAML
+	if err := yaml.Unmarshal(raw, &sub); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	var errs []error
+	for _, rule := range sub.Spec.Routes.Rules {
+		if rule.Match == "" {
+			continue
+		}
+		if _, err := routing.Compile(rule.Match, rule.Path); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
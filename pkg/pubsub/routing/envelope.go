@@ -0,0 +1,70 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routing
+
+import "encoding/json"
+
+// Envelope is the variable environment Match expressions are compiled
+// and evaluated against. It mirrors the CloudEvents 1.0 envelope rather
+// than the raw TopicEventRequest so that rules read the same whether
+// they run in the sidecar's dispatch path or in a subscriber app.
+type Envelope struct {
+	Type            string         `expr:"type"`
+	Source          string         `expr:"source"`
+	Subject         string         `expr:"subject"`
+	ID              string         `expr:"id"`
+	DataContentType string         `expr:"datacontenttype"`
+	Extensions      map[string]any `expr:"extensions"`
+	// Data holds the event payload parsed as JSON when DataContentType is
+	// application/json (or a +json suffix); otherwise it is the raw
+	// []byte body, which most Match expressions won't touch directly.
+	Data any `expr:"data"`
+}
+
+// env is the top-level expression environment: Match expressions refer
+// to the event as `event.type`, `event.source`, `event.data.priority`,
+// and so on, matching the naming used in subscription YAML today.
+type env struct {
+	Event Envelope `expr:"event"`
+}
+
+// NewEnvelope builds the evaluation environment for a single event. If
+// contentType indicates JSON, data is decoded so expressions can index
+// into it (e.g. `event.data.priority > 5`); otherwise it is exposed
+// as-is.
+func NewEnvelope(id, eventType, source, subject, contentType string, extensions map[string]any, data []byte) Envelope {
+	env := Envelope{
+		Type:            eventType,
+		Source:          source,
+		Subject:         subject,
+		ID:              id,
+		DataContentType: contentType,
+		Extensions:      extensions,
+		Data:            data,
+	}
+
+	if isJSONContentType(contentType) && len(data) > 0 {
+		var parsed any
+		if err := json.Unmarshal(data, &parsed); err == nil {
+			env.Data = parsed
+		}
+	}
+
+	return env
+}
+
+func isJSONContentType(contentType string) bool {
+	return contentType == "application/json" ||
+		len(contentType) > len("+json") && contentType[len(contentType)-len("+json"):] == "+json"
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routing
+
+import "fmt"
+
+// CompileError is returned by Compile when a Match expression fails to
+// parse or type-check, e.g. a typo'd field name or a non-boolean result.
+type CompileError struct {
+	Match string
+	Err   error
+}
+
+func (e *CompileError) Error() string {
+	return fmt.Sprintf("invalid match rule %q: %v", e.Match, e.Err)
+}
+
+func (e *CompileError) Unwrap() error {
+	return e.Err
+}
+
+// EvalError is returned by Rule.Evaluate when a compiled rule fails at
+// runtime, e.g. the event's data shape doesn't match what the
+// expression assumed.
+type EvalError struct {
+	Match string
+	Err   error
+}
+
+func (e *EvalError) Error() string {
+	return fmt.Sprintf("failed to evaluate match rule %q: %v", e.Match, e.Err)
+}
+
+func (e *EvalError) Unwrap() error {
+	return e.Err
+}
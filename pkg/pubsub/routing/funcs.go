@@ -0,0 +1,43 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routing
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// hasField implements the `has(event.data.field)` Match function, used
+// to test for the presence of an optional field without a nil panic.
+// Because expr already short-circuits a nil-map/nil-field access to the
+// zero value, has simply reports whether that access produced a
+// non-nil, non-empty result.
+func hasField(args ...any) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("has: expected 1 argument, got %d", len(args))
+	}
+	v := args[0]
+	if v == nil {
+		return false, nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() { //nolint:exhaustive
+	case reflect.String:
+		return rv.Len() > 0, nil
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return rv.Len() > 0, nil
+	default:
+		return true, nil
+	}
+}
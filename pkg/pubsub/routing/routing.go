@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package routing compiles and evaluates the `Match` expressions carried
+// on a subscription's TopicRule. Rules are compiled once, at subscription
+// registration, and evaluated per event against the full CloudEvents
+// envelope, so the cost of parsing an expression is never paid on the
+// hot path.
+package routing
+
+import (
+	"fmt"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// Rule is a Match expression that has been compiled against the Envelope
+// environment and is ready to be evaluated per event.
+type Rule struct {
+	// Path is the subscriber-defined route this rule selects when it
+	// evaluates to true, copied from the originating TopicRule.
+	Path string
+
+	source  string
+	program *vm.Program
+}
+
+// Compile parses and type-checks a Match expression against the Envelope
+// environment, returning a Rule that can be evaluated repeatedly without
+// re-parsing. It returns a *CompileError if match is not a valid,
+// boolean-typed expression.
+//
+// String prefix and regex matching use expr's built-in `startsWith` and
+// `matches` infix operators (e.g. `event.source startsWith "orders/"`,
+// `event.subject matches "^order-[0-9]+$"`) rather than custom functions
+// of the same name: expr reserves both names as operators, so a custom
+// function registered under either name is never reachable by call
+// syntax. `has` has no such collision and is registered below.
+func Compile(match, path string) (*Rule, error) {
+	program, err := expr.Compile(match, expr.Env(env{}), expr.AsBool(), expr.Function("has", hasField))
+	if err != nil {
+		return nil, &CompileError{Match: match, Err: err}
+	}
+	return &Rule{Path: path, source: match, program: program}, nil
+}
+
+// String returns the original Match expression the rule was compiled
+// from, useful for logging and the routelint CLI.
+func (r *Rule) String() string {
+	return r.source
+}
+
+// Evaluate runs the compiled rule against env and reports whether it
+// matched. A non-nil error indicates the expression panicked at runtime
+// (e.g. a nil field dereference) rather than that it didn't match.
+func (r *Rule) Evaluate(e Envelope) (bool, error) {
+	out, err := expr.Run(r.program, env{Event: e})
+	if err != nil {
+		return false, &EvalError{Match: r.source, Err: err}
+	}
+	matched, ok := out.(bool)
+	if !ok {
+		return false, &EvalError{Match: r.source, Err: fmt.Errorf("expression did not evaluate to a bool, got %T", out)}
+	}
+	return matched, nil
+}
+
+// Route evaluates each already-compiled rule in order and returns the
+// Path of the first one that matches env, or ok=false if none do
+// (callers should fall back to the subscription's default route).
+func Route(rules []*Rule, env Envelope) (path string, ok bool, err error) {
+	for _, rule := range rules {
+		matched, err := rule.Evaluate(env)
+		if err != nil {
+			return "", false, err
+		}
+		if matched {
+			return rule.Path, true, nil
+		}
+	}
+	return "", false, nil
+}
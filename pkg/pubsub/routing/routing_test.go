@@ -0,0 +1,105 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompile(t *testing.T) {
+	t.Run("valid boolean expression compiles", func(t *testing.T) {
+		rule, err := Compile(`event.type == "myevent.C"`, "/myevent.C")
+		require.NoError(t, err)
+		assert.Equal(t, "/myevent.C", rule.Path)
+	})
+
+	t.Run("non-boolean expression is rejected", func(t *testing.T) {
+		_, err := Compile(`event.type`, "/myevent.C")
+		var compileErr *CompileError
+		assert.ErrorAs(t, err, &compileErr)
+	})
+
+	t.Run("unknown field is rejected", func(t *testing.T) {
+		_, err := Compile(`event.bogus == "x"`, "/myevent.C")
+		var compileErr *CompileError
+		assert.ErrorAs(t, err, &compileErr)
+	})
+}
+
+func TestRuleEvaluate(t *testing.T) {
+	t.Run("matches on data priority and source", func(t *testing.T) {
+		rule, err := Compile(`event.data.priority > 5 && event.source startsWith "orders/"`, "/high-priority")
+		require.NoError(t, err)
+
+		env := NewEnvelope("1", "myevent.A", "orders/123", "", "application/json", nil, []byte(`{"priority": 9}`))
+		matched, err := rule.Evaluate(env)
+		require.NoError(t, err)
+		assert.True(t, matched)
+
+		env = NewEnvelope("1", "myevent.A", "shipping/123", "", "application/json", nil, []byte(`{"priority": 9}`))
+		matched, err = rule.Evaluate(env)
+		require.NoError(t, err)
+		assert.False(t, matched)
+	})
+
+	t.Run("matches with regex", func(t *testing.T) {
+		rule, err := Compile(`event.subject matches "^order-[0-9]+$"`, "/orders")
+		require.NoError(t, err)
+
+		env := NewEnvelope("1", "myevent.A", "", "order-42", "application/json", nil, nil)
+		matched, err := rule.Evaluate(env)
+		require.NoError(t, err)
+		assert.True(t, matched)
+	})
+
+	t.Run("has checks extension presence", func(t *testing.T) {
+		rule, err := Compile(`has(event.extensions["traceparent"])`, "/traced")
+		require.NoError(t, err)
+
+		env := NewEnvelope("1", "myevent.A", "", "", "application/json", map[string]any{"traceparent": "00-abc"}, nil)
+		matched, err := rule.Evaluate(env)
+		require.NoError(t, err)
+		assert.True(t, matched)
+
+		env = NewEnvelope("1", "myevent.A", "", "", "application/json", nil, nil)
+		matched, err = rule.Evaluate(env)
+		require.NoError(t, err)
+		assert.False(t, matched)
+	})
+}
+
+func TestRoute(t *testing.T) {
+	ruleB, err := Compile(`event.type == "myevent.B"`, "/myevent.B")
+	require.NoError(t, err)
+	ruleC, err := Compile(`event.type == "myevent.C"`, "/myevent.C")
+	require.NoError(t, err)
+
+	t.Run("first matching rule wins", func(t *testing.T) {
+		env := NewEnvelope("1", "myevent.C", "", "", "", nil, nil)
+		path, ok, err := Route([]*Rule{ruleC, ruleB}, env)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "/myevent.C", path)
+	})
+
+	t.Run("falls back when nothing matches", func(t *testing.T) {
+		env := NewEnvelope("1", "myevent.A", "", "", "", nil, nil)
+		_, ok, err := Route([]*Rule{ruleC, ruleB}, env)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,279 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package streaming multiplexes pubsub broker deliveries onto the
+// per-(pubsub, topic) streams opened by pull-style subscriber apps (see
+// the Dapr.SubscribeTopicEventsAlpha1 RPC), instead of pushing each
+// message through a unary OnTopicEvent call. It is transport-agnostic by
+// design: a caller adapts whatever stream type it holds to the
+// EventStream interface below, and this package only owns ordering and
+// fan-out.
+//
+// Nothing in this repository snapshot calls Multiplexer yet. The
+// intended caller is the sidecar's handler for Dapr.SubscribeTopicEventsAlpha1,
+// which would adapt its generated server stream to EventStream, Register
+// it per (pubsubName, topic), and call Dispatch from its broker-delivery
+// path instead of (or alongside) the unary AppCallback.OnTopicEvent call.
+// That handler lives in the sidecar runtime, which this snapshot does
+// not include, so this package is exercised only by its own tests for
+// now; tests/apps/pubsub-subscriber-routing_grpc/stream_client.go is the
+// subscriber (client) side of the same RPC and talks to it directly
+// through the generated Dapr client, not through this package.
+package streaming
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+
+	pb "github.com/dapr/dapr/pkg/proto/runtime/v1"
+)
+
+// StreamedEvent is a single delivery attempt sent down a subscriber's
+// stream, carrying an AckID the subscriber must echo back in its Ack so
+// the Multiplexer can correlate the reply to the in-flight delivery.
+type StreamedEvent struct {
+	AckID   string
+	Request *pb.TopicEventRequest
+}
+
+// Ack is a subscriber's reply to a previously delivered StreamedEvent.
+type Ack struct {
+	AckID  string
+	Status pb.TopicEventResponse_TopicEventResponseStatus //nolint:nosnakecase
+}
+
+// EventStream is the minimal send/receive surface a transport adapter
+// (e.g. one wrapping the server stream of Dapr.SubscribeTopicEventsAlpha1)
+// must implement to be driven by a Multiplexer. Recv is only ever called from the
+// Multiplexer's single per-stream receive loop, so implementations do
+// not need to support concurrent Recv calls.
+type EventStream interface {
+	Send(*StreamedEvent) error
+	Recv() (*Ack, error)
+}
+
+// topicKey identifies one subscriber stream by the (pubsub, topic) pair
+// it was opened for.
+type topicKey struct {
+	pubsubName string
+	topic      string
+}
+
+// subscriberStream tracks one registered EventStream: the single
+// receive loop that demultiplexes Acks by AckID, and one FIFO queue per
+// partition key so deliveries sharing a key stay strictly ordered while
+// independent keys proceed concurrently.
+type subscriberStream struct {
+	stream EventStream
+	done   chan struct{}
+
+	mu      sync.Mutex
+	closed  bool
+	pending map[string]chan *Ack
+	queues  map[string]chan *StreamedEvent
+}
+
+// Multiplexer routes broker deliveries to the correct subscriber stream
+// for its (pubsub, topic), preserving per-partition-key ordering.
+type Multiplexer struct {
+	mu      sync.Mutex
+	streams map[topicKey]*subscriberStream
+}
+
+// NewMultiplexer returns an empty Multiplexer ready to accept
+// subscriber registrations.
+func NewMultiplexer() *Multiplexer {
+	return &Multiplexer{streams: make(map[topicKey]*subscriberStream)}
+}
+
+// Register binds stream as the delivery target for (pubsubName, topic)
+// and starts its single Ack receive loop. The returned unregister func
+// must be called once the stream closes; it stops new dispatches from
+// being accepted and signals every partition-key's drain goroutine to
+// exit, rather than leaking one goroutine and channel per partition key
+// ever used.
+func (m *Multiplexer) Register(pubsubName, topic string, stream EventStream) (unregister func()) {
+	sub := &subscriberStream{
+		stream:  stream,
+		done:    make(chan struct{}),
+		pending: make(map[string]chan *Ack),
+		queues:  make(map[string]chan *StreamedEvent),
+	}
+	go sub.recvLoop()
+
+	m.mu.Lock()
+	k := topicKey{pubsubName: pubsubName, topic: topic}
+	m.streams[k] = sub
+	m.mu.Unlock()
+
+	return func() {
+		m.mu.Lock()
+		if m.streams[k] == sub {
+			delete(m.streams, k)
+		}
+		m.mu.Unlock()
+
+		sub.close()
+	}
+}
+
+// close marks sub as no longer accepting dispatches and signals every
+// partition-key's drain goroutine, via done, to stop. done is only ever
+// closed, never sent on, so this is safe to race against concurrent
+// dispatch calls without risking a send on a closed channel.
+func (s *subscriberStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.done)
+}
+
+// Dispatch hands a broker delivery to the registered stream for its
+// (pubsubName, topic), blocking until the subscriber acks it (or ctx is
+// canceled). Deliveries sharing partitionKey are serialized relative to
+// each other; deliveries with different keys proceed independently.
+func (m *Multiplexer) Dispatch(ctx context.Context, pubsubName, topic, partitionKey string, req *pb.TopicEventRequest) (*Ack, error) {
+	m.mu.Lock()
+	sub, ok := m.streams[topicKey{pubsubName: pubsubName, topic: topic}]
+	m.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no subscriber stream registered for pubsub %q topic %q", pubsubName, topic)
+	}
+
+	return sub.dispatch(ctx, partitionKey, req)
+}
+
+func (s *subscriberStream) dispatch(ctx context.Context, partitionKey string, req *pb.TopicEventRequest) (*Ack, error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("subscriber stream was unregistered")
+	}
+	queue, ok := s.queues[partitionKey]
+	if !ok {
+		queue = make(chan *StreamedEvent, 1)
+		s.queues[partitionKey] = queue
+		go s.drain(queue)
+	}
+	s.mu.Unlock()
+
+	ackID := uuid.New().String()
+	// Room for two copies of the ack: one for this call, one for drain.
+	waiter := make(chan *Ack, 2)
+	s.mu.Lock()
+	s.pending[ackID] = waiter
+	s.mu.Unlock()
+
+	select {
+	case queue <- &StreamedEvent{AckID: ackID, Request: req}:
+	case <-s.done:
+		// drain never saw this delivery, so it will never look for this
+		// ackID; safe to forget it here.
+		s.forgetPending(ackID)
+		return nil, fmt.Errorf("subscriber stream was unregistered")
+	case <-ctx.Done():
+		s.forgetPending(ackID)
+		return nil, ctx.Err()
+	}
+
+	select {
+	case ack := <-waiter:
+		return ack, nil
+	case <-s.done:
+		// The delivery already reached drain, which is still waiting on
+		// this same ackID (possibly for the real ack, possibly to be
+		// unblocked by done itself); leave the entry for drain to settle
+		// instead of deleting it out from under it.
+		return nil, fmt.Errorf("subscriber stream was unregistered")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// forgetPending removes ackID from s.pending; it is only safe to call
+// for an ackID that drain never received (i.e. before the queue send in
+// dispatch succeeds), since drain may otherwise still be waiting on it.
+func (s *subscriberStream) forgetPending(ackID string) {
+	s.mu.Lock()
+	delete(s.pending, ackID)
+	s.mu.Unlock()
+}
+
+// drain is the single sender for one partition key: it holds the next
+// event back until the previous one's Ack has been demultiplexed by
+// recvLoop, giving the key an in-flight window of exactly one message.
+// It exits once its stream is unregistered, rather than blocking forever
+// on a queue nothing will ever close, and it also stops waiting on a
+// stalled subscriber's Ack the moment the stream is unregistered instead
+// of blocking on it forever.
+func (s *subscriberStream) drain(queue chan *StreamedEvent) {
+	for {
+		select {
+		case event := <-queue:
+			// Look the waiter up before Send: recvLoop removes it from
+			// pending as soon as the ack arrives.
+			waiter := s.waiterFor(event.AckID)
+			if err := s.stream.Send(event); err != nil {
+				return
+			}
+			select {
+			case <-waiter:
+			case <-s.done:
+				// The subscriber never acked before the stream was
+				// unregistered; recvLoop will never match this ackID now,
+				// so forget it rather than leave it pending forever.
+				s.forgetPending(event.AckID)
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *subscriberStream) waiterFor(ackID string) chan *Ack {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending[ackID]
+}
+
+// recvLoop is the single reader of the underlying stream: it reads each
+// Ack as it arrives and forwards it to both the dispatch call waiting on
+// that AckID and the drain goroutine holding back that key's next event,
+// so out-of-order acks across partition keys never block one another.
+func (s *subscriberStream) recvLoop() {
+	for {
+		ack, err := s.stream.Recv()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		waiter, ok := s.pending[ack.AckID]
+		if ok {
+			delete(s.pending, ack.AckID)
+		}
+		s.mu.Unlock()
+
+		if ok {
+			waiter <- ack
+			waiter <- ack
+		}
+	}
+}
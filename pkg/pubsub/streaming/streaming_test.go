@@ -0,0 +1,140 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package streaming
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/dapr/dapr/pkg/proto/runtime/v1"
+)
+
+// fakeStream is an in-memory EventStream that immediately acks whatever
+// it is sent with SUCCESS, used to drive the Multiplexer without a real
+// gRPC transport.
+type fakeStream struct {
+	sent chan *StreamedEvent
+	acks chan *Ack
+}
+
+func newFakeStream() *fakeStream {
+	return &fakeStream{
+		sent: make(chan *StreamedEvent, 16),
+		acks: make(chan *Ack, 16),
+	}
+}
+
+func (f *fakeStream) Send(event *StreamedEvent) error {
+	f.sent <- event
+	f.acks <- &Ack{AckID: event.AckID, Status: pb.TopicEventResponse_SUCCESS} //nolint:nosnakecase
+	return nil
+}
+
+func (f *fakeStream) Recv() (*Ack, error) {
+	return <-f.acks, nil
+}
+
+// neverAckStream is an EventStream whose Send always succeeds but whose
+// Recv never returns, simulating a subscriber that goes silent (crashes
+// or hangs) right after a delivery went out.
+type neverAckStream struct {
+	sent  chan *StreamedEvent
+	block chan struct{}
+}
+
+func newNeverAckStream() *neverAckStream {
+	return &neverAckStream{
+		sent:  make(chan *StreamedEvent, 16),
+		block: make(chan struct{}),
+	}
+}
+
+func (n *neverAckStream) Send(event *StreamedEvent) error {
+	n.sent <- event
+	return nil
+}
+
+func (n *neverAckStream) Recv() (*Ack, error) {
+	<-n.block
+	return nil, nil
+}
+
+func TestMultiplexerDispatch(t *testing.T) {
+	t.Run("dispatch fails with no registered stream", func(t *testing.T) {
+		m := NewMultiplexer()
+		_, err := m.Dispatch(context.Background(), "messagebus", "orders", "", &pb.TopicEventRequest{})
+		assert.Error(t, err)
+	})
+
+	t.Run("dispatch delivers and returns the ack", func(t *testing.T) {
+		m := NewMultiplexer()
+		stream := newFakeStream()
+		unregister := m.Register("messagebus", "orders", stream)
+		defer unregister()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		ack, err := m.Dispatch(ctx, "messagebus", "orders", "order-1", &pb.TopicEventRequest{Id: "evt-1"})
+		require.NoError(t, err)
+		assert.Equal(t, pb.TopicEventResponse_SUCCESS, ack.Status) //nolint:nosnakecase
+	})
+
+	t.Run("same partition key is delivered in order", func(t *testing.T) {
+		m := NewMultiplexer()
+		stream := newFakeStream()
+		unregister := m.Register("messagebus", "orders", stream)
+		defer unregister()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		for i := 0; i < 5; i++ {
+			_, err := m.Dispatch(ctx, "messagebus", "orders", "order-1", &pb.TopicEventRequest{Id: string(rune('a' + i))})
+			require.NoError(t, err)
+		}
+
+		for i := 0; i < 5; i++ {
+			event := <-stream.sent
+			assert.Equal(t, string(rune('a'+i)), event.Request.Id)
+		}
+	})
+
+	t.Run("drain stops waiting on a stalled subscriber once unregistered", func(t *testing.T) {
+		m := NewMultiplexer()
+		stream := newNeverAckStream()
+		unregister := m.Register("messagebus", "orders", stream)
+
+		before := runtime.NumGoroutine()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := m.Dispatch(ctx, "messagebus", "orders", "order-1", &pb.TopicEventRequest{Id: "evt-1"})
+		assert.Error(t, err)
+
+		// The event reached drain's Send; only its Ack never arrives.
+		<-stream.sent
+
+		unregister()
+
+		assert.Eventually(t, func() bool {
+			return runtime.NumGoroutine() <= before
+		}, time.Second, 10*time.Millisecond, "drain goroutine leaked past unregister")
+	})
+}
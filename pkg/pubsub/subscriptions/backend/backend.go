@@ -0,0 +1,41 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package backend defines the SubscriptionStore extension point used for
+// durable subscription bookkeeping: routed-message sets, ack cursors, and
+// dedup windows. Concrete stores register themselves by name with the
+// init subpackage, mirroring how Terraform's backend/init registry lets
+// state backends plug in without the caller depending on every
+// implementation directly.
+package backend
+
+import "context"
+
+// SubscriptionStore persists the bookkeeping a subscriber needs to
+// process deliveries idempotently: which messages have already been
+// routed to which route, keyed by an opaque route name (e.g. a
+// TopicRule.Path).
+type SubscriptionStore interface {
+	// Insert records that message was routed to route. Implementations
+	// must make this safe to call more than once for the same
+	// (route, message) pair without producing duplicates, since at-least-
+	// once delivery means the same message can arrive again.
+	Insert(ctx context.Context, route, message string) error
+
+	// List returns every message recorded against route, in no
+	// particular order.
+	List(ctx context.Context, route string) ([]string, error)
+
+	// Reset clears all recorded state, used to start a test run clean.
+	Reset(ctx context.Context) error
+}
@@ -0,0 +1,30 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package consul reserves the "consul" SubscriptionStore backend name.
+// The store itself (routed messages kept under a KV prefix per route)
+// is not implemented yet; New returns an error so that selecting
+// "consul" fails fast and visibly instead of silently falling through
+// to another backend.
+package consul
+
+import (
+	"fmt"
+
+	"github.com/dapr/dapr/pkg/pubsub/subscriptions/backend"
+)
+
+// New always fails: the consul backend is reserved but not implemented.
+func New() (backend.SubscriptionStore, error) {
+	return nil, fmt.Errorf("subscriptions/backend/consul: not yet implemented")
+}
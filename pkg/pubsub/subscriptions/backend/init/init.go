@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package init is the SubscriptionStore registry: Init wires up every
+// supported backend's factory under its name, and callers look one up
+// by name with New instead of importing backend implementations
+// directly. This mirrors Terraform's backend/init package, which wires
+// every supported state backend into a name->factory map in one place
+// so the rest of the CLI only ever depends on that map.
+package init
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/dapr/dapr/pkg/pubsub/subscriptions/backend"
+	"github.com/dapr/dapr/pkg/pubsub/subscriptions/backend/consul"
+	"github.com/dapr/dapr/pkg/pubsub/subscriptions/backend/etcd"
+	"github.com/dapr/dapr/pkg/pubsub/subscriptions/backend/inmem"
+	"github.com/dapr/dapr/pkg/pubsub/subscriptions/backend/postgres"
+	"github.com/dapr/dapr/pkg/pubsub/subscriptions/backend/redis"
+)
+
+// Factory constructs a new, unconfigured SubscriptionStore instance.
+type Factory func() (backend.SubscriptionStore, error)
+
+var (
+	once       sync.Once
+	backendsMu sync.RWMutex
+	backends   = make(map[string]Factory)
+)
+
+// Init populates the registry with every backend this build was
+// compiled with. It is idempotent and safe to call from multiple
+// goroutines; callers typically invoke it once at startup before the
+// first New call.
+func Init() {
+	once.Do(func() {
+		Register("inmem", func() (backend.SubscriptionStore, error) {
+			return inmem.New(), nil
+		})
+		Register("redis", redis.New)
+		Register("postgres", postgres.New)
+		Register("consul", consul.New)
+		Register("etcd", etcd.New)
+	})
+}
+
+// Register associates name with factory so that New(name) can construct
+// it later. It panics on a duplicate name, since that indicates Init (or
+// a test) registered the same backend twice, a programming error rather
+// than a runtime condition.
+func Register(name string, factory Factory) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if _, exists := backends[name]; exists {
+		panic(fmt.Sprintf("subscriptions/backend: Register called twice for backend %q", name))
+	}
+	backends[name] = factory
+}
+
+// New constructs the backend registered under name. Callers typically
+// select name from an env var or config field at startup, after Init
+// has been called.
+func New(name string) (backend.SubscriptionStore, error) {
+	backendsMu.RLock()
+	factory, ok := backends[name]
+	backendsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("subscriptions/backend: unknown backend %q, registered backends: %v", name, Names())
+	}
+	return factory()
+}
+
+// Names returns the currently registered backend names, useful for error
+// messages and a --list-backends style CLI flag.
+func Names() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package init
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInit(t *testing.T) {
+	Init()
+
+	t.Run("inmem is usable out of the box", func(t *testing.T) {
+		store, err := New("inmem")
+		require.NoError(t, err)
+		require.NoError(t, store.Insert(nil, "route-a", "msg-1")) //nolint:staticcheck
+
+		messages, err := store.List(nil, "route-a") //nolint:staticcheck
+		require.NoError(t, err)
+		assert.Equal(t, []string{"msg-1"}, messages)
+	})
+
+	t.Run("unimplemented backends fail fast instead of silently falling back", func(t *testing.T) {
+		for _, name := range []string{"redis", "postgres", "consul", "etcd"} {
+			_, err := New(name)
+			assert.Error(t, err, name)
+		}
+	})
+
+	t.Run("unknown backend name is rejected", func(t *testing.T) {
+		_, err := New("bogus")
+		assert.Error(t, err)
+	})
+}
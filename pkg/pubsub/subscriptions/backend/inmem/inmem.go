@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package inmem is the default SubscriptionStore: an in-process,
+// non-durable store backed by a set per route. It is what the routing
+// test app used directly before the backend registry existed, and
+// remains the right choice whenever the store doesn't need to survive a
+// process restart or be shared across replicas.
+package inmem
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Store is a SubscriptionStore that keeps routed messages in memory,
+// deduplicated per route via sets.String.
+type Store struct {
+	mu     sync.Mutex
+	routes map[string]sets.String
+}
+
+// New returns an empty in-memory Store.
+func New() *Store {
+	return &Store{routes: make(map[string]sets.String)}
+}
+
+// Insert records message against route, idempotently.
+func (s *Store) Insert(_ context.Context, route, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, ok := s.routes[route]
+	if !ok {
+		set = sets.NewString()
+		s.routes[route] = set
+	}
+	set.Insert(message)
+	return nil
+}
+
+// List returns every message recorded against route.
+func (s *Store) List(_ context.Context, route string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, ok := s.routes[route]
+	if !ok {
+		return []string{}, nil
+	}
+	return set.List(), nil
+}
+
+// Reset clears every route's recorded messages.
+func (s *Store) Reset(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.routes = make(map[string]sets.String)
+	return nil
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inmem
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("insert is idempotent and list returns what was inserted", func(t *testing.T) {
+		s := New()
+		require.NoError(t, s.Insert(ctx, "route-a", "msg-1"))
+		require.NoError(t, s.Insert(ctx, "route-a", "msg-1"))
+		require.NoError(t, s.Insert(ctx, "route-a", "msg-2"))
+
+		messages, err := s.List(ctx, "route-a")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"msg-1", "msg-2"}, messages)
+	})
+
+	t.Run("list on an unknown route returns an empty slice", func(t *testing.T) {
+		s := New()
+		messages, err := s.List(ctx, "route-a")
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("reset clears every route", func(t *testing.T) {
+		s := New()
+		require.NoError(t, s.Insert(ctx, "route-a", "msg-1"))
+		require.NoError(t, s.Reset(ctx))
+
+		messages, err := s.List(ctx, "route-a")
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+}
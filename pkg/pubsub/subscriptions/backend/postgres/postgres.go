@@ -0,0 +1,31 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package postgres reserves the "postgres" SubscriptionStore backend
+// name. The store itself (a routed_messages table keyed by route and
+// message, deduplicated with an upsert) is not implemented yet; New
+// returns an error so that selecting "postgres" fails fast and visibly
+// instead of silently falling through to another backend.
+package postgres
+
+import (
+	"fmt"
+
+	"github.com/dapr/dapr/pkg/pubsub/subscriptions/backend"
+)
+
+// New always fails: the postgres backend is reserved but not
+// implemented.
+func New() (backend.SubscriptionStore, error) {
+	return nil, fmt.Errorf("subscriptions/backend/postgres: not yet implemented")
+}
@@ -16,23 +16,26 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"net"
 	"net/url"
 	"os"
 	"os/signal"
-	"sync"
 	"syscall"
 
+	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
 	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/emptypb"
-	"k8s.io/apimachinery/pkg/util/sets"
 
 	commonv1pb "github.com/dapr/dapr/pkg/proto/common/v1"
 	pb "github.com/dapr/dapr/pkg/proto/runtime/v1"
+	"github.com/dapr/dapr/pkg/pubsub/routing"
+	"github.com/dapr/dapr/pkg/pubsub/subscriptions/backend"
+	backendinit "github.com/dapr/dapr/pkg/pubsub/subscriptions/backend/init"
 )
 
 const (
@@ -46,6 +49,12 @@ const (
 	pathD = "myevent.D"
 	pathE = "myevent.E"
 	pathF = "myevent.F"
+
+	// subscriptionBackendEnvVar selects the SubscriptionStore backend
+	// this app records routed messages in, by name from the
+	// pkg/pubsub/subscriptions/backend registry. Defaults to "inmem" so
+	// the existing single-process test suite keeps working unchanged.
+	subscriptionBackendEnvVar = "DAPR_TEST_SUBSCRIPTION_BACKEND"
 )
 
 type routedMessagesResponse struct {
@@ -57,21 +66,32 @@ type routedMessagesResponse struct {
 	RouteF []string `json:"route-f"`
 }
 
-var (
-	// using sets to make the test idempotent on multiple delivery of same message.
-	routedMessagesA sets.String
-	routedMessagesB sets.String
-	routedMessagesC sets.String
-	routedMessagesD sets.String
-	routedMessagesE sets.String
-	routedMessagesF sets.String
-	lock            sync.Mutex
-)
+// routePaths is every route path routedMessagesResponse can report on,
+// in the order Insert/List are keyed.
+var routePaths = []string{pathA, pathB, pathC, pathD, pathE, pathF}
 
 // server is our user app.
-type server struct{}
+type server struct {
+	// handle is the fully composed CloudEvents middleware chain that
+	// OnTopicEvent runs every incoming event through before routing.
+	handle eventHandler
+	// publisher optionally sends CloudEvents back out to Dapr, selected
+	// at startup via the --protocol flag.
+	publisher *cePublisher
+	// rules is topicRules compiled through pkg/pubsub/routing, used to
+	// independently cross-check the path the sidecar chose.
+	rules []*routing.Rule
+	// store records which messages have been routed to which path,
+	// making delivery idempotent under at-least-once redelivery. Backed
+	// by whichever pkg/pubsub/subscriptions/backend implementation
+	// DAPR_TEST_SUBSCRIPTION_BACKEND selects.
+	store backend.SubscriptionStore
+}
 
 func main() {
+	protocolFlag := flag.String("protocol", string(protocolHTTP), "protocol used to publish cloudevents to Dapr (http or grpc)")
+	flag.Parse()
+
 	log.Printf("Initializing grpc")
 
 	/* #nosec */
@@ -80,16 +100,38 @@ func main() {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
-	lock.Lock()
-	initializeSets()
-	lock.Unlock()
+	backendinit.Init()
+	backendName := os.Getenv(subscriptionBackendEnvVar)
+	if backendName == "" {
+		backendName = "inmem"
+	}
+	store, err := backendinit.New(backendName)
+	if err != nil {
+		log.Fatalf("failed to create subscription store: %v", err)
+	}
+
+	publisher, err := newCEPublisher(protocol(*protocolFlag))
+	if err != nil {
+		log.Fatalf("failed to create cloudevents publisher: %v", err)
+	}
+
+	rules, err := compileTopicRules()
+	if err != nil {
+		log.Fatalf("failed to compile topic rules: %v", err)
+	}
+
+	srv := &server{publisher: publisher, rules: rules, store: store}
+	srv.handle = chainMiddleware(srv.routeEvent, tracingMiddleware, validationMiddleware, deadLetterMiddleware)
 
 	/* #nosec */
 	s := grpc.NewServer()
-	pb.RegisterAppCallbackServer(s, &server{})
+	pb.RegisterAppCallbackServer(s, srv)
 
 	log.Println("Client starting...")
 
+	streamCtx, stopStreaming := context.WithCancel(context.Background())
+	go srv.runStreamingSubscriber(streamCtx, fmt.Sprintf("127.0.0.1:%d", daprGRPCPort))
+
 	// Stop the gRPC server when we get a termination signal
 	stopCh := make(chan os.Signal, 1)
 	signal.Notify(stopCh, syscall.SIGKILL, syscall.SIGTERM, syscall.SIGINT) //nolint:staticcheck
@@ -97,6 +139,7 @@ func main() {
 		// Wait for cancelation signal
 		<-stopCh
 		log.Println("Shutdown signal received")
+		stopStreaming()
 		s.GracefulStop()
 	}()
 
@@ -106,17 +149,6 @@ func main() {
 	log.Println("App shut down")
 }
 
-// initialize all the sets for a clean test.
-func initializeSets() {
-	// initialize all the sets.
-	routedMessagesA = sets.NewString()
-	routedMessagesB = sets.NewString()
-	routedMessagesC = sets.NewString()
-	routedMessagesD = sets.NewString()
-	routedMessagesE = sets.NewString()
-	routedMessagesF = sets.NewString()
-}
-
 // This method gets invoked when a remote service has called the app through Dapr.
 // The payload carries a Method to identify the method, a set of metadata properties and an optional payload.
 func (s *server) OnInvoke(ctx context.Context, in *commonv1pb.InvokeRequest) (*commonv1pb.InvokeResponse, error) {
@@ -130,33 +162,88 @@ func (s *server) OnInvoke(ctx context.Context, in *commonv1pb.InvokeRequest) (*c
 
 	log.Printf("(%s) Got invoked method %s", reqID, in.Method)
 
-	lock.Lock()
-	defer lock.Unlock()
+	if in.Method == "publish" {
+		if err := s.publish(ctx, in.GetData().GetValue()); err != nil {
+			return nil, err
+		}
+		return &commonv1pb.InvokeResponse{Data: &anypb.Any{}, ContentType: "application/json"}, nil
+	}
 
 	respBody := &anypb.Any{}
 	switch in.Method {
 	case "getMessages":
-		respBody.Value = s.getMessages(reqID)
+		value, err := s.getMessages(ctx, reqID)
+		if err != nil {
+			return nil, err
+		}
+		respBody.Value = value
 	case "initialize":
-		initializeSets()
+		if err := s.store.Reset(ctx); err != nil {
+			return nil, err
+		}
 	}
 
 	return &commonv1pb.InvokeResponse{Data: respBody, ContentType: "application/json"}, nil
 }
 
-func (s *server) getMessages(reqID string) []byte {
+func (s *server) getMessages(ctx context.Context, reqID string) ([]byte, error) {
+	lists := make([][]string, len(routePaths))
+	for i, path := range routePaths {
+		messages, err := s.store.List(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list messages for path %s: %w", path, err)
+		}
+		lists[i] = messages
+	}
+
 	resp := routedMessagesResponse{
-		RouteA: routedMessagesA.List(),
-		RouteB: routedMessagesB.List(),
-		RouteC: routedMessagesC.List(),
-		RouteD: routedMessagesD.List(),
-		RouteE: routedMessagesE.List(),
-		RouteF: routedMessagesF.List(),
+		RouteA: lists[0],
+		RouteB: lists[1],
+		RouteC: lists[2],
+		RouteD: lists[3],
+		RouteE: lists[4],
+		RouteF: lists[5],
 	}
 
 	rawResp, _ := json.Marshal(resp)
 	log.Printf("(%s) getMessages response: %s", reqID, string(rawResp))
-	return rawResp
+	return rawResp, nil
+}
+
+// topicRules are the Match expressions this app subscribes with. They are
+// shared between ListTopicSubscriptions (which ships them to the sidecar
+// for dispatch) and the local routing.Rule set this app compiles with
+// pkg/pubsub/routing to double check the sidecar routed each event the
+// same way a second, independent evaluation would.
+var topicRules = []*commonv1pb.TopicRule{
+	{
+		Match: `event.type == "myevent.C"`,
+		Path:  pathC,
+	},
+	{
+		Match: `event.type == "myevent.B"`,
+		Path:  pathB,
+	},
+	{
+		Match: `event.data.priority > 5 && event.source startsWith "orders/"`,
+		Path:  pathD,
+	},
+}
+
+// compileTopicRules compiles topicRules through pkg/pubsub/routing so
+// the app fails fast on startup if a Match expression is invalid, rather
+// than discovering it when the first event silently falls through to
+// the default route.
+func compileTopicRules() ([]*routing.Rule, error) {
+	rules := make([]*routing.Rule, 0, len(topicRules))
+	for _, r := range topicRules {
+		rule, err := routing.Compile(r.Match, r.Path)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
 }
 
 // Dapr will call this method to get the list of topics the app wants to subscribe to. In this example, we are telling Dapr.
@@ -169,16 +256,7 @@ func (s *server) ListTopicSubscriptions(ctx context.Context, in *emptypb.Empty)
 				PubsubName: pubsubName,
 				Topic:      pubsubTopic,
 				Routes: &commonv1pb.TopicRoutes{
-					Rules: []*commonv1pb.TopicRule{
-						{
-							Match: `event.type == "myevent.C"`,
-							Path:  pathC,
-						},
-						{
-							Match: `event.type == "myevent.B"`,
-							Path:  pathB,
-						},
-					},
+					Rules:   topicRules,
 					Default: pathA,
 				},
 			},
@@ -187,43 +265,94 @@ func (s *server) ListTopicSubscriptions(ctx context.Context, in *emptypb.Empty)
 }
 
 // This method is fired whenever a message has been published to a topic that has been subscribed. Dapr sends published messages in a CloudEvents 1.0 envelope.
+// The raw TopicEventRequest is first decoded into a cloudevents.Event, then
+// run through the validation/tracing/dead-lettering middleware chain before
+// routing; handlers never see the protobuf message directly.
 func (s *server) OnTopicEvent(ctx context.Context, in *pb.TopicEventRequest) (*pb.TopicEventResponse, error) {
-	lock.Lock()
-	defer lock.Unlock()
+	event, err := toCloudEvent(in)
+	if err != nil {
+		log.Printf("dropping malformed cloudevent: %v", err)
+		return &pb.TopicEventResponse{Status: pb.TopicEventResponse_DROP}, nil //nolint:nosnakecase
+	}
+	// The routing path keyed off of TopicRule.Path is still carried as an
+	// extension since it is a Dapr-specific concept, not part of the CE
+	// envelope itself.
+	event.SetExtension("path", in.GetPath())
 
+	status, err := s.handle(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.TopicEventResponse{Status: status}, nil
+}
+
+// routeEvent is the terminal handler in the middleware chain: it records
+// the event's payload against its route in the subscription store, which
+// the test assertions read back via getMessages. Before recording, it
+// audits the path the sidecar assigned against checkRouting; a
+// disagreement on a path topicRules actually covers means topicRules and
+// the compiled pkg/pubsub/routing rules have drifted apart, which is
+// treated as a handler failure so deadLetterMiddleware dead-letters the
+// event instead of silently recording it under a path the test's own
+// rules wouldn't have chosen.
+func (s *server) routeEvent(ctx context.Context, event cloudevents.Event) (pb.TopicEventResponse_TopicEventResponseStatus, error) { //nolint:nosnakecase
 	reqID := uuid.New().String()
-	log.Printf("(%s) Message arrived - Topic: %s, Message: %s, Path: %s", reqID, in.Topic, string(in.Data), in.Path)
-
-	var set *sets.String
-	switch in.Path {
-	case pathA:
-		set = &routedMessagesA
-	case pathB:
-		set = &routedMessagesB
-	case pathC:
-		set = &routedMessagesC
-	case pathD:
-		set = &routedMessagesD
-	case pathE:
-		set = &routedMessagesE
-	case pathF:
-		set = &routedMessagesF
-	default:
-		log.Printf("(%s) Responding with DROP. in.Path not found", reqID)
-		// Return success with DROP status to drop message.
-		return &pb.TopicEventResponse{
-			Status: pb.TopicEventResponse_DROP, //nolint:nosnakecase
-		}, nil
+	path, _ := event.Extensions()["path"].(string)
+	log.Printf("(%s) Message arrived - Topic: %s, Message: %s, Path: %s", reqID, event.Extensions()["topic"], string(event.Data()), path)
+
+	if err := s.checkRouting(event, path); err != nil {
+		return pb.TopicEventResponse_RETRY, fmt.Errorf("(%s) routing rule audit failed: %w", reqID, err) //nolint:nosnakecase
 	}
 
-	msg := string(in.Data)
+	switch path {
+	case pathA, pathB, pathC, pathD, pathE, pathF:
+	default:
+		log.Printf("(%s) Responding with DROP. path not found", reqID)
+		return pb.TopicEventResponse_DROP, nil //nolint:nosnakecase
+	}
 
-	set.Insert(msg)
+	if err := s.store.Insert(ctx, path, string(event.Data())); err != nil {
+		return pb.TopicEventResponse_RETRY, fmt.Errorf("(%s) failed to record routed message: %w", reqID, err) //nolint:nosnakecase
+	}
 
 	log.Printf("(%s) Responding with SUCCESS", reqID)
-	return &pb.TopicEventResponse{
-		Status: pb.TopicEventResponse_SUCCESS, //nolint:nosnakecase
-	}, nil
+	return pb.TopicEventResponse_SUCCESS, nil //nolint:nosnakecase
+}
+
+// topicRulesPaths is the full set of paths topicRules (and its Default)
+// can produce. checkRouting only audits sidecar paths within this set;
+// pathE and pathF are reachable through declarative Subscription rules
+// merged in by the sidecar alongside topicRules, which this app's
+// hardcoded mirror has no way to express, so they are not this audit's
+// business.
+var topicRulesPaths = map[string]bool{pathA: true, pathB: true, pathC: true, pathD: true}
+
+// checkRouting independently evaluates topicRules against event using
+// pkg/pubsub/routing and reports an error if it disagrees with the path
+// the sidecar assigned, for paths topicRules actually covers (see
+// topicRulesPaths). This is a local audit of the test app's own routing
+// config, not the engine the sidecar's dispatch path uses: Dapr's
+// production TopicRule matching lives outside this repo snapshot and is
+// untouched by pkg/pubsub/routing. The value this provides is catching
+// drift between topicRules and the compiled rules before it ships, not
+// changing how any event is actually dispatched.
+func (s *server) checkRouting(event cloudevents.Event, sidecarPath string) error {
+	if !topicRulesPaths[sidecarPath] {
+		return nil
+	}
+
+	env := routing.NewEnvelope(event.ID(), event.Type(), event.Source(), event.Subject(), event.DataContentType(), event.Extensions(), event.Data())
+	want, matched, err := routing.Route(s.rules, env)
+	if err != nil {
+		return fmt.Errorf("local routing evaluation failed: %w", err)
+	}
+	if !matched {
+		want = pathA // topicRules' Default
+	}
+	if want != sidecarPath {
+		return fmt.Errorf("local routing evaluation disagrees with sidecar: want %s, got %s", want, sidecarPath)
+	}
+	return nil
 }
 
 // Dapr will call this method to get the list of bindings the app will get invoked by. In this example, we are telling Dapr.
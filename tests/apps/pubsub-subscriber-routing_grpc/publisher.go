@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/google/uuid"
+
+	cegrpc "github.com/dapr/dapr/tests/apps/pubsub-subscriber-routing_grpc/internal/cegrpc"
+)
+
+// protocol selects the Dapr publish binding a CloudEvent is sent over.
+type protocol string
+
+const (
+	protocolHTTP protocol = "http"
+	protocolGRPC protocol = "grpc"
+
+	daprHTTPPort = 3500
+	daprGRPCPort = 50001
+)
+
+// cePublisher publishes cloudevents.Event values to Dapr over either the
+// HTTP or gRPC binding, so the same routing assertions can be exercised
+// against both transports from one test app.
+type cePublisher struct {
+	client cloudevents.Client
+}
+
+// newCEPublisher builds a cloudevents.Client bound to the requested
+// protocol. HTTP uses the CloudEvents SDK's own binding; gRPC uses the
+// thin binding in ./internal/cegrpc that talks to Dapr's sidecar API.
+func newCEPublisher(proto protocol) (*cePublisher, error) {
+	switch proto {
+	case protocolGRPC:
+		client, err := cegrpc.NewClient(fmt.Sprintf("127.0.0.1:%d", daprGRPCPort))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create grpc cloudevents client: %w", err)
+		}
+		return &cePublisher{client: client}, nil
+	case protocolHTTP, "":
+		t, err := cehttp.New(cehttp.WithTarget(fmt.Sprintf("http://127.0.0.1:%d/v1.0/publish/%s/%s", daprHTTPPort, pubsubName, pubsubTopic)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create http cloudevents transport: %w", err)
+		}
+		client, err := cloudevents.NewClient(t, cloudevents.WithTimeNow(), cloudevents.WithUUIDs())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create http cloudevents client: %w", err)
+		}
+		return &cePublisher{client: client}, nil
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q, expected %q or %q", proto, protocolHTTP, protocolGRPC)
+	}
+}
+
+// Publish sends a single CloudEvent through the configured binding,
+// returning an error if Dapr rejects or fails to deliver it.
+func (p *cePublisher) Publish(ctx context.Context, event cloudevents.Event) error {
+	result := p.client.Send(ctx, event)
+	if !cloudevents.IsACK(result) {
+		return fmt.Errorf("cloudevent %s was not accepted: %w", event.ID(), result)
+	}
+	return nil
+}
+
+// publish is the OnInvoke-triggered entry point the test driver uses to
+// exercise egress: it wraps the raw request payload as a CloudEvent and
+// publishes it back through Dapr over whichever protocol the app was
+// started with.
+func (s *server) publish(ctx context.Context, data []byte) error {
+	event := cloudevents.NewEvent()
+	event.SetID(uuid.New().String())
+	event.SetSource("pubsub-subscriber-routing_grpc")
+	event.SetType("myevent.A")
+	event.SetExtension("pubsubname", pubsubName)
+	event.SetExtension("topic", pubsubTopic)
+	if err := event.SetData(cloudevents.ApplicationJSON, data); err != nil {
+		return fmt.Errorf("failed to set cloudevent data: %w", err)
+	}
+
+	return s.publisher.Publish(ctx, event)
+}